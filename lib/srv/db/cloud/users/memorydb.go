@@ -67,7 +67,8 @@ func (f *memoryDBFetcher) GetType() string {
 
 // FetchDatabaseUsers fetches users for provided database. Implements Fetcher.
 func (f *memoryDBFetcher) FetchDatabaseUsers(ctx context.Context, database types.Database) ([]User, error) {
-	if database.GetAWS().MemoryDB.ACLName == "" {
+	aclNames := aclNamesForMemoryDB(database.GetAWS().MemoryDB)
+	if len(aclNames) == 0 {
 		return nil, nil
 	}
 
@@ -76,19 +77,19 @@ func (f *memoryDBFetcher) FetchDatabaseUsers(ctx context.Context, database types
 		return nil, trace.Wrap(err)
 	}
 
-	secrets, err := newSecretStore(database, f.cfg.Clients)
+	secrets, err := newSecretStore(ctx, database, f.cfg.Clients, f.cfg.Interval)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
-	users := []User{}
-	mdbUsers, err := f.getManagedUsersForACL(ctx, database.GetAWS().Region, database.GetAWS().MemoryDB.ACLName, client)
+	mdbUsers, err := f.getManagedUsersForACLs(ctx, database.GetAWS().Region, aclNames, client)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	users := []User{}
 	for _, mdbUser := range mdbUsers {
-		user, err := f.createUser(mdbUser, client, secrets)
+		user, err := f.createUser(mdbUser.user, mdbUser.aclNames, client, secrets)
 		if err != nil {
 			return nil, trace.Wrap(err)
 		}
@@ -98,17 +99,55 @@ func (f *memoryDBFetcher) FetchDatabaseUsers(ctx context.Context, database types
 	return users, nil
 }
 
-// getManagedUsersForACL returns all managed users for specified ACL.
-func (f *memoryDBFetcher) getManagedUsersForACL(ctx context.Context, region, aclName string, client memorydbiface.MemoryDBAPI) ([]*memorydb.User, error) {
+// aclNamesForMemoryDB returns the set of ACL names a database resource is
+// configured to discover users from. MemoryDB.ACLNames is preferred, with
+// the singular, deprecated MemoryDB.ACLName kept for backwards
+// compatibility with existing database resources.
+func aclNamesForMemoryDB(mdb types.MemoryDB) []string {
+	if len(mdb.ACLNames) > 0 {
+		return mdb.ACLNames
+	}
+	if mdb.ACLName != "" {
+		return []string{mdb.ACLName}
+	}
+	return nil
+}
+
+// managedMemoryDBUser pairs a discovered MemoryDB user with the subset of
+// the requested ACLs it is actually attached to, so callers can restrict
+// which database user a Teleport role may assume based on ACL name.
+type managedMemoryDBUser struct {
+	user     *memorydb.User
+	aclNames []string
+}
+
+// getManagedUsersForACLs returns all managed users attached to any of the
+// specified ACLs, de-duplicated by ARN since the same user is commonly
+// attached to more than one ACL. The result preserves the order returned
+// by DescribeUsers.
+func (f *memoryDBFetcher) getManagedUsersForACLs(ctx context.Context, region string, aclNames []string, client memorydbiface.MemoryDBAPI) ([]managedMemoryDBUser, error) {
 	allUsers, err := f.getUsersForRegion(ctx, region, client)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
-	managedUsers := []*memorydb.User{}
+	managedUsers := []managedMemoryDBUser{}
+	seenARNs := make(map[string]struct{})
 	for _, user := range allUsers {
-		// Match ACL.
-		if !slices.Contains(aws.StringValueSlice(user.ACLNames), aclName) {
+		// Match ACL. A user can be attached to several of the
+		// requested ACLs at once; keep all matches so they can be
+		// surfaced on the returned User.
+		matchedACLs := intersectACLNames(aws.StringValueSlice(user.ACLNames), aclNames)
+		if len(matchedACLs) == 0 {
+			continue
+		}
+
+		arn := aws.StringValue(user.ARN)
+		if arn == "" {
+			f.cfg.Log.Warnf("Skipping MemoryDB user %v with no ARN", aws.StringValue(user.Name))
+			continue
+		}
+		if _, ok := seenARNs[arn]; ok {
 			continue
 		}
 
@@ -117,16 +156,20 @@ func (f *memoryDBFetcher) getManagedUsersForACL(ctx context.Context, region, acl
 		userTags, err := f.getUserTags(ctx, user, client)
 		if err != nil {
 			if trace.IsAccessDenied(err) {
-				f.cfg.Log.WithError(err).Debugf("No Permission to get tags for user %v", aws.StringValue(user.ARN))
+				f.cfg.Log.WithError(err).Debugf("No Permission to get tags for user %v", arn)
 			} else {
-				f.cfg.Log.WithError(err).Warnf("Failed to get tags for user %v", aws.StringValue(user.ARN))
+				f.cfg.Log.WithError(err).Warnf("Failed to get tags for user %v", arn)
 			}
 			continue
 		}
 		for _, tag := range userTags {
 			if aws.StringValue(tag.Key) == libaws.TagKeyTeleportManaged &&
 				libaws.IsTagValueTrue(aws.StringValue(tag.Value)) {
-				managedUsers = append(managedUsers, user)
+				seenARNs[arn] = struct{}{}
+				managedUsers = append(managedUsers, managedMemoryDBUser{
+					user:     user,
+					aclNames: matchedACLs,
+				})
 				break
 			}
 		}
@@ -134,7 +177,21 @@ func (f *memoryDBFetcher) getManagedUsersForACL(ctx context.Context, region, acl
 	return managedUsers, nil
 }
 
+// intersectACLNames returns the ACL names present in both lists.
+func intersectACLNames(userACLs, wantACLs []string) []string {
+	var matched []string
+	for _, acl := range userACLs {
+		if slices.Contains(wantACLs, acl) {
+			matched = append(matched, acl)
+		}
+	}
+	return matched
+}
+
 // getUsersForRegion discovers all MemoryDB users for provided region.
+// The cache is keyed solely by region (not by ACL), so multiple ACLs
+// configured on databases in the same region share one DescribeUsers
+// call instead of refetching per ACL.
 func (f *memoryDBFetcher) getUsersForRegion(ctx context.Context, region string, client memorydbiface.MemoryDBAPI) ([]*memorydb.User, error) {
 	getFunc := func(ctx context.Context) ([]*memorydb.User, error) {
 		var users []*memorydb.User
@@ -184,7 +241,7 @@ func (f *memoryDBFetcher) getUserTags(ctx context.Context, user *memorydb.User,
 }
 
 // createUser creates an MemoryDB User.
-func (f *memoryDBFetcher) createUser(mdbUser *memorydb.User, client memorydbiface.MemoryDBAPI, secrets libsecrets.Secrets) (User, error) {
+func (f *memoryDBFetcher) createUser(mdbUser *memorydb.User, aclNames []string, client memorydbiface.MemoryDBAPI, secrets libsecrets.Secrets) (User, error) {
 	secretKey, err := secretKeyFromAWSARN(aws.StringValue(mdbUser.ARN))
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -200,8 +257,9 @@ func (f *memoryDBFetcher) createUser(mdbUser *memorydb.User, client memorydbifac
 		maxPasswordLength:           128,
 		usePreviousPasswordForLogin: true,
 		cloudResource: &memoryDBUserResource{
-			user:   mdbUser,
-			client: client,
+			user:     mdbUser,
+			aclNames: aclNames,
+			client:   client,
 		},
 	}
 	if err := user.CheckAndSetDefaults(); err != nil {
@@ -212,8 +270,39 @@ func (f *memoryDBFetcher) createUser(mdbUser *memorydb.User, client memorydbifac
 
 // memoryDBUserResource implements cloudResource interface for a MemoryDB user.
 type memoryDBUserResource struct {
-	user   *memorydb.User
-	client memorydbiface.MemoryDBAPI
+	user     *memorydb.User
+	aclNames []string
+	client   memorydbiface.MemoryDBAPI
+}
+
+// ACLNames returns the ACLs, among those Teleport was configured to
+// discover users from, that this user is attached to.
+func (r *memoryDBUserResource) ACLNames() []string {
+	return r.aclNames
+}
+
+// aclNamesGetter is implemented by cloudResources that track ACL
+// membership. It is unexported since callers outside this package only
+// ever see a User, not the concrete cloudResource behind it; they reach
+// it through MemoryDBACLNames instead.
+type aclNamesGetter interface {
+	ACLNames() []string
+}
+
+// MemoryDBACLNames returns the ACLs a User discovered by memoryDBFetcher
+// is attached to, so authorization can restrict which database user a
+// Teleport role may assume based on ACL membership. It returns nil for
+// users from other fetchers, which have no ACL concept.
+func MemoryDBACLNames(user User) []string {
+	base, ok := user.(*baseUser)
+	if !ok {
+		return nil
+	}
+	getter, ok := base.cloudResource.(aclNamesGetter)
+	if !ok {
+		return nil
+	}
+	return getter.ACLNames()
 }
 
 // ModifyUserPassword updates passwords of an MemoryDB user.
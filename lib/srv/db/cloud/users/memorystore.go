@@ -0,0 +1,171 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package users
+
+import (
+	"context"
+
+	"github.com/gravitational/trace"
+	redis "google.golang.org/genproto/googleapis/cloud/redis/v1"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/gravitational/teleport/api/types"
+	libgcp "github.com/gravitational/teleport/lib/cloud/gcp"
+	libsecrets "github.com/gravitational/teleport/lib/srv/db/secrets"
+)
+
+// memorystoreFetcher is a fetcher for discovering GCP Memorystore for
+// Redis users. It mirrors memoryDBFetcher: AUTH-enabled Memorystore
+// instances expose a single AUTH string rather than named users, so the
+// "user" Teleport manages is the instance's AUTH string itself.
+type memorystoreFetcher struct {
+	cfg Config
+}
+
+// newMemorystoreFetcher creates a new instance of the Memorystore fetcher.
+//
+// TODO(chunk0-2): this fetcher is not yet reachable. It still needs to be
+// registered in the Fetcher factory keyed off types.DatabaseTypeMemorystore,
+// and f.cfg.Clients needs a GetGCPRedisClient method backed by a real
+// libgcp.RedisAdminClient constructor, neither of which exist in this tree
+// yet. Wire both up before enabling Memorystore user discovery.
+func newMemorystoreFetcher(cfg Config) (Fetcher, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return &memorystoreFetcher{
+		cfg: cfg,
+	}, nil
+}
+
+// GetType returns the database type of the fetcher. Implements Fetcher.
+func (f *memorystoreFetcher) GetType() string {
+	return types.DatabaseTypeMemorystore
+}
+
+// FetchDatabaseUsers fetches users for provided database. Implements Fetcher.
+func (f *memorystoreFetcher) FetchDatabaseUsers(ctx context.Context, database types.Database) ([]User, error) {
+	gcpMeta := database.GetGCP()
+	if gcpMeta.InstanceID == "" {
+		return nil, nil
+	}
+
+	client, err := f.cfg.Clients.GetGCPRedisClient(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	instance, err := f.getManagedInstance(ctx, gcpMeta, client)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if instance == nil {
+		return nil, nil
+	}
+
+	secrets, err := newSecretStore(ctx, database, f.cfg.Clients, f.cfg.Interval)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	user, err := f.createUser(instance, client, secrets)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return []User{user}, nil
+}
+
+// getManagedInstance looks up the Memorystore instance and returns it if
+// it is AUTH-enabled and tagged as Teleport-managed, nil otherwise.
+func (f *memorystoreFetcher) getManagedInstance(ctx context.Context, gcpMeta types.GCP, client libgcp.RedisAdminClient) (*redis.Instance, error) {
+	instance, err := client.GetInstance(ctx, &redis.GetInstanceRequest{
+		Name: libgcp.RedisInstanceName(gcpMeta.ProjectID, gcpMeta.Location, gcpMeta.InstanceID),
+	})
+	if err != nil {
+		if trace.IsNotFound(libgcp.ConvertAPIError(err)) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(libgcp.ConvertAPIError(err))
+	}
+
+	if !instance.GetAuthEnabled() {
+		return nil, nil
+	}
+	if value, ok := instance.GetLabels()[libgcp.LabelTeleportManaged]; !ok || !libgcp.IsLabelValueTrue(value) {
+		return nil, nil
+	}
+	return instance, nil
+}
+
+// createUser creates a Memorystore User.
+func (f *memorystoreFetcher) createUser(instance *redis.Instance, client libgcp.RedisAdminClient, secrets libsecrets.Secrets) (User, error) {
+	secretKey, err := secretKeyFromGCPName(instance.GetName())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	user := &baseUser{
+		log:                         f.cfg.Log,
+		secretKey:                   secretKey,
+		secrets:                     secrets,
+		secretTTL:                   f.cfg.Interval,
+		databaseUsername:            "default",
+		clock:                       f.cfg.Clock,
+		maxPasswordLength: 128,
+		// Memorystore only keeps a single active AUTH string; unlike
+		// MemoryDB there is no dual old+new AUTH window, so the previous
+		// password stops working the instant ModifyUserPassword returns.
+		usePreviousPasswordForLogin: false,
+		cloudResource: &memorystoreUserResource{
+			instance: instance,
+			client:   client,
+		},
+	}
+	if err := user.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return user, nil
+}
+
+// memorystoreUserResource implements cloudResource interface for a
+// Memorystore instance's AUTH string.
+type memorystoreUserResource struct {
+	instance *redis.Instance
+	client   libgcp.RedisAdminClient
+}
+
+// ModifyUserPassword rotates the AUTH string of a Memorystore instance.
+// The Memorystore API has no dedicated AUTH-string endpoint and no
+// concept of an old/new AUTH pair: auth_string is just a field on the
+// Instance resource, updated via UpdateInstance with a field mask, and
+// the previous value stops being accepted as soon as the update
+// completes. oldPassword is accepted to satisfy cloudResource and
+// ignored.
+func (r *memorystoreUserResource) ModifyUserPassword(ctx context.Context, _, newPassword string) error {
+	_, err := r.client.UpdateInstance(ctx, &redis.UpdateInstanceRequest{
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"auth_string"}},
+		Instance: &redis.Instance{
+			Name:       r.instance.GetName(),
+			AuthString: newPassword,
+		},
+	})
+	if err != nil {
+		return trace.Wrap(libgcp.ConvertAPIError(err))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/cloud"
+	libsecrets "github.com/gravitational/teleport/lib/srv/db/secrets"
+)
+
+// etcdLeaseHeadroom multiplies the fetcher's rotation interval to get the
+// etcd lease TTL, so a single missed rotation (e.g. the agent was down for
+// one interval) doesn't let the lease - and with it the secret, including
+// the previous-password rotation state - expire before the next rotation
+// has a chance to renew it.
+const etcdLeaseHeadroom = 3
+
+// newSecretStore constructs the Secrets implementation selected by the
+// database's AWS.SecretStore field, defaulting to AWS Secrets Manager to
+// preserve existing behavior when the field is unset. rotationInterval is
+// the fetcher's password rotation interval, used to size the etcd
+// provider's lease TTL.
+//
+// Every fetcher in this package must go through newSecretStore with its
+// own rotation interval rather than constructing a Secrets store
+// directly, so the etcd lease TTL always tracks the fetcher that owns
+// the secret; memoryDBFetcher and memorystoreFetcher already do this.
+// Any ElastiCache or Redshift fetcher added to this package must do the
+// same (none exist in this tree yet).
+func newSecretStore(ctx context.Context, database types.Database, clients cloud.Clients, rotationInterval time.Duration) (libsecrets.Secrets, error) {
+	aws := database.GetAWS()
+
+	cfg := libsecrets.Config{
+		Provider: libsecrets.Provider(aws.SecretStore.Provider),
+	}
+
+	switch cfg.Provider {
+	case libsecrets.ProviderEtcd:
+		client, err := clients.GetEtcdClient(aws.SecretStore.Etcd)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		cfg.Etcd = libsecrets.EtcdConfig{
+			Client: client,
+			Prefix: aws.SecretStore.Etcd.Prefix,
+			TTL:    rotationInterval * etcdLeaseHeadroom,
+		}
+	case libsecrets.ProviderFile:
+		cfg.File = libsecrets.FileConfig{
+			Path: aws.SecretStore.File.Path,
+		}
+	default:
+		// Default (and empty) provider: AWS Secrets Manager, the
+		// original behavior before pluggable secret stores.
+		cfg.Provider = libsecrets.ProviderAWSSecretsManager
+		client, err := clients.GetAWSSecretsManagerClient(aws.Region)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		cfg.AWS = libsecrets.AWSConfig{
+			Client:   client,
+			KMSKeyID: aws.SecretStore.AWS.KMSKeyID,
+		}
+	}
+
+	secrets, err := libsecrets.New(ctx, cfg)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/gravitational/trace"
+
+	libaws "github.com/gravitational/teleport/lib/cloud/aws"
+)
+
+func init() {
+	RegisterProvider(ProviderAWSSecretsManager, newAWSSecretsManager)
+}
+
+// AWSConfig configures the AWS Secrets Manager provider.
+type AWSConfig struct {
+	// Client is the AWS Secrets Manager client used to create and
+	// access secrets. Required.
+	Client secretsmanageriface.SecretsManagerAPI
+	// KMSKeyID is an optional customer managed KMS key used to encrypt
+	// secrets. If empty, the default aws/secretsmanager key is used.
+	KMSKeyID string
+}
+
+// awsSecretsManager stores secrets as versioned AWS Secrets Manager
+// secrets, using the AWSCURRENT and AWSPREVIOUS version stages to hold
+// the current and previous passwords respectively.
+type awsSecretsManager struct {
+	cfg AWSConfig
+}
+
+func newAWSSecretsManager(_ context.Context, cfg Config) (Secrets, error) {
+	if cfg.AWS.Client == nil {
+		return nil, trace.BadParameter("missing AWS Secrets Manager client")
+	}
+	return &awsSecretsManager{cfg: cfg.AWS}, nil
+}
+
+// Create creates a new secret with an initial value.
+func (s *awsSecretsManager) Create(ctx context.Context, key Key, value string) error {
+	input := &secretsmanager.CreateSecretInput{
+		Name:         aws.String(string(key)),
+		SecretString: aws.String(value),
+	}
+	if s.cfg.KMSKeyID != "" {
+		input.KmsKeyId = aws.String(s.cfg.KMSKeyID)
+	}
+
+	_, err := s.cfg.Client.CreateSecretWithContext(ctx, input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceExistsException {
+			return trace.AlreadyExists("secret %v already exists", key)
+		}
+		return trace.Wrap(libaws.ConvertRequestFailureError(err))
+	}
+	return nil
+}
+
+// PutValue sets a new current value, demoting the existing current value
+// to previous so that rotation does not lock out in-flight connections.
+// If previousValue is supplied, it is staged as AWSPREVIOUS explicitly
+// instead, overriding the value Secrets Manager would otherwise demote -
+// used to seed state when adopting a secret created out of band.
+func (s *awsSecretsManager) PutValue(ctx context.Context, key Key, value, previousValue string) error {
+	if previousValue == "" {
+		_, err := s.cfg.Client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(string(key)),
+			SecretString: aws.String(value),
+		})
+		if err != nil {
+			return trace.Wrap(libaws.ConvertRequestFailureError(err))
+		}
+		return nil
+	}
+
+	// Specifying VersionStages suppresses Secrets Manager's automatic
+	// demotion of the prior AWSCURRENT value to AWSPREVIOUS, so we can
+	// stage previousValue there ourselves in a second call.
+	if _, err := s.cfg.Client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(string(key)),
+		SecretString:  aws.String(value),
+		VersionStages: aws.StringSlice([]string{"AWSCURRENT"}),
+	}); err != nil {
+		return trace.Wrap(libaws.ConvertRequestFailureError(err))
+	}
+
+	if _, err := s.cfg.Client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(string(key)),
+		SecretString:  aws.String(previousValue),
+		VersionStages: aws.StringSlice([]string{"AWSPREVIOUS"}),
+	}); err != nil {
+		return trace.Wrap(libaws.ConvertRequestFailureError(err))
+	}
+	return nil
+}
+
+// GetValue retrieves the requested version of a secret's value.
+func (s *awsSecretsManager) GetValue(ctx context.Context, key Key, version Version) (string, error) {
+	output, err := s.cfg.Client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(string(key)),
+		VersionStage: aws.String(stageForVersion(version)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return "", trace.NotFound("secret %v not found", key)
+		}
+		return "", trace.Wrap(libaws.ConvertRequestFailureError(err))
+	}
+	return aws.StringValue(output.SecretString), nil
+}
+
+// DeleteSecret deletes a secret and all its versions.
+func (s *awsSecretsManager) DeleteSecret(ctx context.Context, key Key) error {
+	_, err := s.cfg.Client.DeleteSecretWithContext(ctx, &secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(string(key)),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil {
+		return trace.Wrap(libaws.ConvertRequestFailureError(err))
+	}
+	return nil
+}
+
+func stageForVersion(version Version) string {
+	if version == PreviousVersion {
+		return "AWSPREVIOUS"
+	}
+	return "AWSCURRENT"
+}
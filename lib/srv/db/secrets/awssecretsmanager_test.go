@@ -0,0 +1,135 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretsManagerClient implements secretsmanageriface.SecretsManagerAPI
+// by embedding it (nil) and overriding only the methods awsSecretsManager
+// calls, the usual pattern for faking a large AWS SDK interface.
+type fakeSecretsManagerClient struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	secrets map[string]map[string]string // secret id -> version stage -> value
+	putErr  error
+}
+
+func newFakeSecretsManagerClient() *fakeSecretsManagerClient {
+	return &fakeSecretsManagerClient{secrets: make(map[string]map[string]string)}
+}
+
+func (f *fakeSecretsManagerClient) CreateSecretWithContext(_ aws.Context, input *secretsmanager.CreateSecretInput, _ ...request.Option) (*secretsmanager.CreateSecretOutput, error) {
+	id := aws.StringValue(input.Name)
+	if _, ok := f.secrets[id]; ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "already exists", nil)
+	}
+	f.secrets[id] = map[string]string{"AWSCURRENT": aws.StringValue(input.SecretString)}
+	return &secretsmanager.CreateSecretOutput{Name: input.Name}, nil
+}
+
+func (f *fakeSecretsManagerClient) PutSecretValueWithContext(_ aws.Context, input *secretsmanager.PutSecretValueInput, _ ...request.Option) (*secretsmanager.PutSecretValueOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	id := aws.StringValue(input.SecretId)
+	versions, ok := f.secrets[id]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+
+	stages := aws.StringValueSlice(input.VersionStages)
+	if len(stages) == 0 {
+		// No explicit stages: mimic Secrets Manager's automatic behavior
+		// of demoting the prior AWSCURRENT to AWSPREVIOUS.
+		versions["AWSPREVIOUS"] = versions["AWSCURRENT"]
+		versions["AWSCURRENT"] = aws.StringValue(input.SecretString)
+		return &secretsmanager.PutSecretValueOutput{}, nil
+	}
+	for _, stage := range stages {
+		versions[stage] = aws.StringValue(input.SecretString)
+	}
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecretsManagerClient) GetSecretValueWithContext(_ aws.Context, input *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	id := aws.StringValue(input.SecretId)
+	versions, ok := f.secrets[id]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	value, ok := versions[aws.StringValue(input.VersionStage)]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func (f *fakeSecretsManagerClient) DeleteSecretWithContext(_ aws.Context, input *secretsmanager.DeleteSecretInput, _ ...request.Option) (*secretsmanager.DeleteSecretOutput, error) {
+	delete(f.secrets, aws.StringValue(input.SecretId))
+	return &secretsmanager.DeleteSecretOutput{}, nil
+}
+
+func TestAWSSecretsManagerPutValueDemotesCurrentToPrevious(t *testing.T) {
+	client := newFakeSecretsManagerClient()
+	s, err := newAWSSecretsManager(context.Background(), Config{AWS: AWSConfig{Client: client}})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Create(context.Background(), "key", "v1"))
+	require.NoError(t, s.PutValue(context.Background(), "key", "v2", ""))
+
+	current, err := s.GetValue(context.Background(), "key", CurrentVersion)
+	require.NoError(t, err)
+	require.Equal(t, "v2", current)
+
+	previous, err := s.GetValue(context.Background(), "key", PreviousVersion)
+	require.NoError(t, err)
+	require.Equal(t, "v1", previous)
+}
+
+func TestAWSSecretsManagerPutValueExplicitPreviousOverridesDemotion(t *testing.T) {
+	client := newFakeSecretsManagerClient()
+	s, err := newAWSSecretsManager(context.Background(), Config{AWS: AWSConfig{Client: client}})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Create(context.Background(), "key", "v1"))
+	require.NoError(t, s.PutValue(context.Background(), "key", "v2", "seeded"))
+
+	previous, err := s.GetValue(context.Background(), "key", PreviousVersion)
+	require.NoError(t, err)
+	require.Equal(t, "seeded", previous)
+}
+
+func TestAWSSecretsManagerCreateAlreadyExists(t *testing.T) {
+	client := newFakeSecretsManagerClient()
+	s, err := newAWSSecretsManager(context.Background(), Config{AWS: AWSConfig{Client: client}})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Create(context.Background(), "key", "v1"))
+	err = s.Create(context.Background(), "key", "v1")
+	require.True(t, trace.IsAlreadyExists(err), "expected AlreadyExists, got %v", err)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/gravitational/trace"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	RegisterProvider(ProviderEtcd, newEtcdSecrets)
+}
+
+// EtcdConfig configures the etcd v3 provider.
+type EtcdConfig struct {
+	// Client is the etcd v3 client used to read and write secrets.
+	// Required.
+	Client *clientv3.Client
+	// Prefix is prepended to every secret key, so multiple Teleport
+	// deployments can safely share an etcd cluster. Defaults to
+	// "/teleport/db/secrets".
+	Prefix string
+	// TTL is the lease duration granted to each secret. The lease is
+	// kept alive for as long as the secret exists and is renewed on
+	// every PutValue, so it only takes effect if Teleport stops
+	// maintaining the secret (e.g. the database was deleted, or the
+	// agent has been down longer than TTL). Defaults to 24 hours.
+	// Callers that rotate on a longer interval than that must set TTL
+	// with headroom above their rotation interval, or the lease - and
+	// the secret, including the previous-password rotation state - will
+	// expire and be deleted by etcd before the next rotation renews it.
+	TTL time.Duration
+}
+
+// CheckAndSetDefaults validates the config and applies defaults.
+func (c *EtcdConfig) CheckAndSetDefaults() error {
+	if c.Client == nil {
+		return trace.BadParameter("missing etcd client")
+	}
+	if c.Prefix == "" {
+		c.Prefix = "/teleport/db/secrets"
+	}
+	if c.TTL <= 0 {
+		c.TTL = 24 * time.Hour
+	}
+	return nil
+}
+
+// secretValue is the JSON document stored at a secret's etcd key. Keeping
+// both versions in a single value lets PutValue demote current to
+// previous atomically via a single compare-and-swap on ModRevision.
+type secretValue struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous"`
+}
+
+// etcdSecrets stores secrets as JSON values under leased etcd keys,
+// using the key's ModRevision for optimistic concurrency so concurrent
+// rotations cannot silently clobber each other's "previous password".
+type etcdSecrets struct {
+	cfg EtcdConfig
+}
+
+func newEtcdSecrets(_ context.Context, cfg Config) (Secrets, error) {
+	if err := cfg.Etcd.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &etcdSecrets{cfg: cfg.Etcd}, nil
+}
+
+func (s *etcdSecrets) keyPath(key Key) string {
+	return path.Join(s.cfg.Prefix, string(key))
+}
+
+// Create creates a new secret under a fresh lease with an initial value.
+func (s *etcdSecrets) Create(ctx context.Context, key Key, value string) error {
+	lease, err := s.cfg.Client.Grant(ctx, int64(s.cfg.TTL.Seconds()))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	data, err := json.Marshal(secretValue{Current: value})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	keyPath := s.keyPath(key)
+	// Only create the key if it does not already exist (CreateRevision
+	// of 0 means absent), so concurrent agents cannot stomp on each
+	// other's secret.
+	txn := s.cfg.Client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(keyPath), "=", 0)).
+		Then(clientv3.OpPut(keyPath, string(data), clientv3.WithLease(lease.ID)))
+
+	resp, err := txn.Commit()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !resp.Succeeded {
+		// The key was created by someone else in the meantime; release
+		// our lease instead of leaving it to accumulate as an orphan
+		// until it expires on its own.
+		if _, err := s.cfg.Client.Revoke(ctx, lease.ID); err != nil {
+			return trace.NewAggregate(trace.AlreadyExists("secret %v already exists", key), trace.Wrap(err))
+		}
+		return trace.AlreadyExists("secret %v already exists", key)
+	}
+	return nil
+}
+
+// PutValue demotes the current value to previous (or overrides it with
+// previousValue when provided) and stores the new current value, retrying
+// on ModRevision conflicts from concurrent rotations.
+func (s *etcdSecrets) PutValue(ctx context.Context, key Key, value, previousValue string) error {
+	keyPath := s.keyPath(key)
+
+	for {
+		getResp, err := s.cfg.Client.Get(ctx, keyPath)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if len(getResp.Kvs) == 0 {
+			return trace.NotFound("secret %v not found", key)
+		}
+		kv := getResp.Kvs[0]
+
+		var existing secretValue
+		if err := json.Unmarshal(kv.Value, &existing); err != nil {
+			return trace.Wrap(err)
+		}
+
+		next := secretValue{Current: value, Previous: existing.Current}
+		if previousValue != "" {
+			next.Previous = previousValue
+		}
+		data, err := json.Marshal(next)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+
+		leaseID, err := s.leaseForKey(ctx, clientv3.LeaseID(kv.Lease))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+
+		// Only commit if nothing has changed the key since we read it.
+		txn := s.cfg.Client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(keyPath), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(keyPath, string(data), clientv3.WithLease(leaseID)))
+
+		resp, err := txn.Commit()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		// Lost the race with a concurrent writer; retry with the
+		// latest ModRevision.
+	}
+}
+
+// leaseForKey reuses and refreshes existing if the key already has a
+// lease attached, or grants a fresh one otherwise (e.g. the key was
+// created out of band with no lease). Granting a brand-new lease on
+// every PutValue call, as opposed to reusing the one already attached
+// to the key, would orphan the previous lease - it is detached from the
+// key by the overwriting Put but never revoked, so it lingers until it
+// expires on its own.
+func (s *etcdSecrets) leaseForKey(ctx context.Context, existing clientv3.LeaseID) (clientv3.LeaseID, error) {
+	if existing != 0 {
+		if _, err := s.cfg.Client.KeepAliveOnce(ctx, existing); err == nil {
+			return existing, nil
+		}
+		// The lease expired or was revoked out from under us; fall
+		// through and grant a new one.
+	}
+
+	lease, err := s.cfg.Client.Grant(ctx, int64(s.cfg.TTL.Seconds()))
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return lease.ID, nil
+}
+
+// GetValue retrieves the requested version of a secret's value.
+func (s *etcdSecrets) GetValue(ctx context.Context, key Key, version Version) (string, error) {
+	resp, err := s.cfg.Client.Get(ctx, s.keyPath(key))
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", trace.NotFound("secret %v not found", key)
+	}
+
+	var value secretValue
+	if err := json.Unmarshal(resp.Kvs[0].Value, &value); err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	if version == PreviousVersion {
+		if value.Previous == "" {
+			return "", trace.NotFound("secret %v has no previous version", key)
+		}
+		return value.Previous, nil
+	}
+	return value.Current, nil
+}
+
+// DeleteSecret deletes a secret and all its versions.
+func (s *etcdSecrets) DeleteSecret(ctx context.Context, key Key) error {
+	_, err := s.cfg.Client.Delete(ctx, s.keyPath(key))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdConfigCheckAndSetDefaults(t *testing.T) {
+	t.Run("missing client rejected", func(t *testing.T) {
+		cfg := EtcdConfig{}
+		require.Error(t, cfg.CheckAndSetDefaults())
+	})
+
+	t.Run("defaults applied", func(t *testing.T) {
+		cfg := EtcdConfig{Client: &clientv3.Client{}}
+		require.NoError(t, cfg.CheckAndSetDefaults())
+		require.Equal(t, "/teleport/db/secrets", cfg.Prefix)
+		require.Equal(t, 24*time.Hour, cfg.TTL)
+	})
+
+	t.Run("explicit values preserved", func(t *testing.T) {
+		cfg := EtcdConfig{Client: &clientv3.Client{}, Prefix: "/custom", TTL: 2 * time.Hour}
+		require.NoError(t, cfg.CheckAndSetDefaults())
+		require.Equal(t, "/custom", cfg.Prefix)
+		require.Equal(t, 2*time.Hour, cfg.TTL)
+	})
+}
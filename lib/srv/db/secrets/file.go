@@ -0,0 +1,177 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+func init() {
+	RegisterProvider(ProviderFile, newFileSecrets)
+}
+
+// FileConfig configures the filesystem/JSON provider, intended for
+// air-gapped installs with no external key/value store available.
+type FileConfig struct {
+	// Path is the JSON file secrets are persisted to. Defaults to
+	// "/var/lib/teleport/db_secrets.json". The containing directory is
+	// created with mode 0700 if it does not exist; it must be writable by
+	// the Teleport agent, and should be restricted to the agent's user as
+	// it holds plaintext passwords.
+	Path string
+}
+
+// CheckAndSetDefaults validates the config and applies defaults.
+func (c *FileConfig) CheckAndSetDefaults() error {
+	if c.Path == "" {
+		c.Path = "/var/lib/teleport/db_secrets.json"
+	}
+	return nil
+}
+
+// fileSecrets stores secrets as a single JSON document on disk, keyed by
+// secret Key. A mutex serializes access since the whole file is
+// rewritten on every mutation; this is adequate for the small number of
+// managed database users a single agent typically handles.
+type fileSecrets struct {
+	cfg FileConfig
+	mu  sync.Mutex
+}
+
+func newFileSecrets(_ context.Context, cfg Config) (Secrets, error) {
+	if err := cfg.File.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &fileSecrets{cfg: cfg.File}, nil
+}
+
+func (s *fileSecrets) load() (map[Key]secretValue, error) {
+	data, err := os.ReadFile(s.cfg.Path)
+	if os.IsNotExist(err) {
+		return map[Key]secretValue{}, nil
+	}
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+
+	secrets := map[Key]secretValue{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return secrets, nil
+}
+
+func (s *fileSecrets) save(secrets map[Key]secretValue) error {
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0700); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	// Secrets contain plaintext database passwords, keep them readable
+	// only by the owner.
+	if err := os.WriteFile(s.cfg.Path, data, 0600); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return nil
+}
+
+// Create creates a new secret with an initial value.
+func (s *fileSecrets) Create(_ context.Context, key Key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if _, ok := secrets[key]; ok {
+		return trace.AlreadyExists("secret %v already exists", key)
+	}
+
+	secrets[key] = secretValue{Current: value}
+	return trace.Wrap(s.save(secrets))
+}
+
+// PutValue demotes the current value to previous (or overrides it with
+// previousValue when provided) and stores the new current value.
+func (s *fileSecrets) PutValue(_ context.Context, key Key, value, previousValue string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	existing, ok := secrets[key]
+	if !ok {
+		return trace.NotFound("secret %v not found", key)
+	}
+
+	next := secretValue{Current: value, Previous: existing.Current}
+	if previousValue != "" {
+		next.Previous = previousValue
+	}
+	secrets[key] = next
+	return trace.Wrap(s.save(secrets))
+}
+
+// GetValue retrieves the requested version of a secret's value.
+func (s *fileSecrets) GetValue(_ context.Context, key Key, version Version) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", trace.NotFound("secret %v not found", key)
+	}
+
+	if version == PreviousVersion {
+		if value.Previous == "" {
+			return "", trace.NotFound("secret %v has no previous version", key)
+		}
+		return value.Previous, nil
+	}
+	return value.Current, nil
+}
+
+// DeleteSecret deletes a secret and all its versions.
+func (s *fileSecrets) DeleteSecret(_ context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	delete(secrets, key)
+	return trace.Wrap(s.save(secrets))
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package secrets provides a pluggable abstraction for storing the rotated
+// passwords Teleport manages for cloud database users (e.g. MemoryDB,
+// ElastiCache, Redshift). Implementations are registered by name and
+// selected per-database, so a deployment is not forced to depend on a
+// particular cloud provider's secret store.
+package secrets
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// Key is the lookup key for a secret, typically derived from the cloud
+// resource ARN or ID that owns the rotated password.
+type Key string
+
+// Version identifies which generation of a secret's value is being read.
+// Teleport keeps the current and previous passwords side by side so that
+// a database user can still authenticate with the old password while the
+// new one propagates (see baseUser.usePreviousPasswordForLogin).
+type Version string
+
+const (
+	// CurrentVersion is the active password a database user should use.
+	CurrentVersion Version = "current"
+	// PreviousVersion is the password that was active before the last
+	// rotation, kept around to avoid locking users out mid-rotation.
+	PreviousVersion Version = "previous"
+)
+
+// Secrets defines a common interface for storing and retrieving the
+// current and previous passwords of a cloud database user. Providers must
+// preserve the "previous password" rotation invariant: PutValue always
+// demotes the existing current value to previous rather than discarding
+// it.
+type Secrets interface {
+	// Create creates a new secret with an initial value. Create returns
+	// trace.AlreadyExists if the secret already exists.
+	Create(ctx context.Context, key Key, value string) error
+
+	// PutValue updates a secret, demoting its current value to previous.
+	// If previousValue is non-empty it overrides the demoted value,
+	// which providers use to seed state when adopting a secret that was
+	// created out of band.
+	PutValue(ctx context.Context, key Key, value, previousValue string) error
+
+	// GetValue retrieves the requested version of a secret's value.
+	// GetValue returns trace.NotFound if the secret, or the requested
+	// version of it, does not exist.
+	GetValue(ctx context.Context, key Key, version Version) (string, error)
+
+	// DeleteSecret deletes a secret and all its versions.
+	DeleteSecret(ctx context.Context, key Key) error
+}
+
+// Provider is the name of a registered Secrets implementation, configurable
+// per-database via the AWS.SecretStore field. There is no agent-wide
+// setting; each database selects its own provider.
+type Provider string
+
+const (
+	// ProviderAWSSecretsManager stores secrets in AWS Secrets Manager.
+	// This is the default, matching Teleport's original behavior.
+	ProviderAWSSecretsManager Provider = "awssecretsmanager"
+	// ProviderEtcd stores secrets as keys in an etcd v3 cluster.
+	ProviderEtcd Provider = "etcd"
+	// ProviderFile stores secrets as JSON on the local filesystem, for
+	// air-gapped installs with no external key/value store available.
+	ProviderFile Provider = "file"
+)
+
+// NewFunc constructs a Secrets implementation from the generic Config.
+type NewFunc func(ctx context.Context, config Config) (Secrets, error)
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[Provider]NewFunc)
+)
+
+// RegisterProvider makes a Secrets implementation available under name.
+// It is called from the init() function of each provider's file.
+func RegisterProvider(name Provider, newFunc NewFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[name] = newFunc
+}
+
+// Config holds the parameters shared by all Secrets providers. Only the
+// fields relevant to the selected Provider are required; unused fields are
+// ignored.
+type Config struct {
+	// Provider selects which registered implementation to construct.
+	Provider Provider
+	// AWS holds configuration for ProviderAWSSecretsManager.
+	AWS AWSConfig
+	// Etcd holds configuration for ProviderEtcd.
+	Etcd EtcdConfig
+	// File holds configuration for ProviderFile.
+	File FileConfig
+}
+
+// CheckAndSetDefaults validates the config and applies sane defaults for
+// the selected provider.
+func (c *Config) CheckAndSetDefaults() error {
+	if c.Provider == "" {
+		c.Provider = ProviderAWSSecretsManager
+	}
+	return nil
+}
+
+// New constructs the Secrets implementation selected by cfg.Provider.
+func New(ctx context.Context, cfg Config) (Secrets, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	mu.RLock()
+	newFunc, ok := providers[cfg.Provider]
+	mu.RUnlock()
+	if !ok {
+		return nil, trace.BadParameter("unknown secret store provider %q", cfg.Provider)
+	}
+
+	secrets, err := newFunc(ctx, cfg)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigCheckAndSetDefaults(t *testing.T) {
+	cfg := Config{}
+	require.NoError(t, cfg.CheckAndSetDefaults())
+	require.Equal(t, ProviderAWSSecretsManager, cfg.Provider)
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	_, err := New(context.Background(), Config{Provider: "not-a-real-provider"})
+	require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+}
+
+func TestNewDefaultsToAWSSecretsManager(t *testing.T) {
+	_, err := New(context.Background(), Config{})
+	// AWSConfig.Client is nil, so construction should fail validating that,
+	// not fall through to "unknown provider" - confirming the empty
+	// Provider defaulted to ProviderAWSSecretsManager before dispatch.
+	require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+	require.ErrorContains(t, err, "Secrets Manager client")
+}
@@ -15,6 +15,7 @@
 package stream
 
 import (
+	"encoding/binary"
 	"errors"
 	"io"
 	"sync"
@@ -28,6 +29,29 @@ import (
 // size is between 16KiB to 64KiB.
 const MaxChunkSize int = 1024 * 16
 
+const (
+	// minChunkSize and maxChunkSize bound Config.ChunkSize to the
+	// documented 16KiB-64KiB sweet spot.
+	minChunkSize = 1024 * 16
+	maxChunkSize = 1024 * 64
+)
+
+// frameType distinguishes control frames from data frames once flow
+// control is enabled. Each framed message sent on the wire is a single
+// type byte followed by the frame's payload.
+type frameType byte
+
+const (
+	// frameData carries a chunk of the caller's Write data.
+	frameData frameType = 0
+	// frameWindowUpdate grants the peer additional send credit, letting
+	// it know that many more bytes have been read and acknowledged.
+	frameWindowUpdate frameType = 1
+	// frameHandshake negotiates ChunkSize and WindowSize before the
+	// first data frame is sent.
+	frameHandshake frameType = 2
+)
+
 // Source is a common interface for grpc client and server streams
 // that transport opaque data.
 type Source interface {
@@ -35,27 +59,132 @@ type Source interface {
 	Recv() ([]byte, error)
 }
 
+// Config controls the chunk size and optional credit-based flow control
+// used by a ReadWriter. The zero value reproduces the ReadWriter's
+// original behavior: fixed MaxChunkSize frames with no flow control and
+// no change to the wire format, so existing consumers of NewReadWriter
+// keep working unchanged.
+type Config struct {
+	// ChunkSize is the maximum number of bytes sent in a single data
+	// frame. Defaults to MaxChunkSize. Must be between 16KiB and 64KiB,
+	// the sweet spot for most links per
+	// https://github.com/grpc/grpc.github.io/issues/371.
+	ChunkSize int
+
+	// WindowSize is the number of unacknowledged bytes a writer may
+	// have outstanding before Write blocks waiting for a window-update
+	// frame from the peer's reader. Only used when FlowControl is
+	// enabled. Defaults to 4*ChunkSize.
+	WindowSize int
+
+	// FlowControl enables credit-based windowing: Read periodically
+	// sends window-update frames back through Source.Send as data is
+	// consumed, and Write blocks once outstanding unacked bytes exceed
+	// WindowSize. Enabling this changes the wire format so that every
+	// message sent on the source starts with a one-byte frame type
+	// (0=data, 1=window-update, 2=handshake) followed by the frame's
+	// payload, so it must be enabled on both ends of the stream.
+	//
+	// A blocked Write opportunistically receives from the source itself
+	// to pick up window-update (and handshake) frames, so a caller that
+	// only ever calls Write - e.g. io.Copy into the ReadWriter for a
+	// file transfer or session recording upload, with nothing calling
+	// Read on the same ReadWriter - still makes progress instead of
+	// blocking forever. If Read is called concurrently from another
+	// goroutine, as is typical for a bidirectional proxy, Write defers
+	// to it and only drains frames itself while Read is idle.
+	FlowControl bool
+
+	// Handshake negotiates ChunkSize and WindowSize with the peer via a
+	// handshake frame exchanged before the first data frame, settling
+	// on the smaller of the two sides' proposals. Only takes effect
+	// when FlowControl is enabled.
+	Handshake bool
+}
+
+// CheckAndSetDefaults validates the config and applies defaults.
+func (c *Config) CheckAndSetDefaults() error {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = MaxChunkSize
+	}
+	if c.ChunkSize < minChunkSize || c.ChunkSize > maxChunkSize {
+		return trace.BadParameter("chunk size must be between %d and %d bytes", minChunkSize, maxChunkSize)
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = c.ChunkSize * 4
+	}
+	// A window smaller than the chunk size can never admit a single
+	// chunk, so awaitWriteCredit would block on the very first Write
+	// forever.
+	if c.WindowSize < c.ChunkSize {
+		return trace.BadParameter("window size (%d) must be at least the chunk size (%d)", c.WindowSize, c.ChunkSize)
+	}
+	if c.Handshake && !c.FlowControl {
+		return trace.BadParameter("handshake requires flow control to be enabled")
+	}
+	return nil
+}
+
 // ReadWriter wraps a grpc source with an [io.ReadWriter] interface.
 // All reads are consumed from [Source.Recv] and all writes and sent
 // via [Source.Send].
 type ReadWriter struct {
 	source Source
+	cfg    Config
 
 	wLock  sync.Mutex
 	rLock  sync.Mutex
 	rBytes []byte
+
+	// sendMu serializes every call to source.Send, including the
+	// window-update acks Read sends on behalf of the caller, since grpc
+	// streams do not support concurrent Send calls from multiple
+	// goroutines.
+	sendMu sync.Mutex
+
+	// The following fields implement the optional credit-based flow
+	// control and are only touched when cfg.FlowControl is set.
+	flowMu      sync.Mutex
+	flowCond    *sync.Cond
+	outstanding int
+	windowSize  int
+	readUnacked int
+	// handshakeSent and handshakeReceived are tracked separately:
+	// a peer that opts into Handshake sends its handshake as the first
+	// frame, so this side's Read can observe and apply the peer's
+	// handshake before this side's own Write has had a chance to send
+	// its. Conflating the two into a single flag would make
+	// sendHandshakeOnce skip sending this side's handshake in that
+	// ordering, so the peer never learns this side's negotiated
+	// ChunkSize/WindowSize.
+	handshakeSent     bool
+	handshakeReceived bool
 }
 
 // NewReadWriter creates a new ReadWriter that leverages the provided
-// source to retrieve data from and write data to.
+// source to retrieve data from and write data to. It is equivalent to
+// NewReadWriterConfig(source, Config{}).
 func NewReadWriter(source Source) (*ReadWriter, error) {
+	return NewReadWriterConfig(source, Config{})
+}
+
+// NewReadWriterConfig creates a new ReadWriter with a tuned chunk size
+// and, optionally, credit-based flow control. See Config for details.
+func NewReadWriterConfig(source Source, cfg Config) (*ReadWriter, error) {
 	if source == nil {
 		return nil, trace.BadParameter("parameter source required")
 	}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
 
-	return &ReadWriter{
-		source: source,
-	}, nil
+	c := &ReadWriter{
+		source:     source,
+		cfg:        cfg,
+		windowSize: cfg.WindowSize,
+	}
+	c.flowCond = sync.NewCond(&c.flowMu)
+	return c, nil
 }
 
 // Read returns data received from the stream source. Any
@@ -67,20 +196,16 @@ func (c *ReadWriter) Read(b []byte) (n int, err error) {
 	c.rLock.Lock()
 	defer c.rLock.Unlock()
 
-	if len(c.rBytes) == 0 {
-		data, err := c.source.Recv()
-		if errors.Is(err, io.EOF) {
-			return 0, io.EOF
-		}
+	for len(c.rBytes) == 0 {
+		data, err := c.recvFrame()
 		if err != nil {
-			return 0, trace.ConnectionProblem(err, "failed to receive from source")
+			return 0, err
 		}
-
-		if data == nil {
-			return 0, trace.BadParameter("received invalid data from source")
+		// A nil, nil result means a control frame was consumed and
+		// handled internally; keep reading until a data frame arrives.
+		if data != nil {
+			c.rBytes = data
 		}
-
-		c.rBytes = data
 	}
 
 	n = copy(b, c.rBytes)
@@ -91,26 +216,100 @@ func (c *ReadWriter) Read(b []byte) (n int, err error) {
 		c.rBytes = nil
 	}
 
+	if c.cfg.FlowControl {
+		if err := c.ackRead(n); err != nil {
+			return n, err
+		}
+	}
+
 	return n, nil
 }
 
+// recvFrame receives the next message from the source and, when flow
+// control is enabled, decodes its frame type. Control frames (window
+// updates and handshakes) are applied internally and reported back as a
+// nil payload with a nil error; callers should keep looping in that case.
+func (c *ReadWriter) recvFrame() ([]byte, error) {
+	data, err := c.source.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, trace.ConnectionProblem(err, "failed to receive from source")
+	}
+	if data == nil {
+		return nil, trace.BadParameter("received invalid data from source")
+	}
+	if !c.cfg.FlowControl {
+		return data, nil
+	}
+	if len(data) == 0 {
+		return nil, trace.BadParameter("received empty framed message from source")
+	}
+
+	switch frameType(data[0]) {
+	case frameData:
+		return data[1:], nil
+	case frameWindowUpdate:
+		if len(data) < 9 {
+			return nil, trace.BadParameter("received malformed window-update frame")
+		}
+		c.grantWriteCredit(int(binary.BigEndian.Uint64(data[1:9])))
+		return nil, nil
+	case frameHandshake:
+		if len(data) < 9 {
+			return nil, trace.BadParameter("received malformed handshake frame")
+		}
+		c.applyHandshake(data[1:9])
+		return nil, nil
+	default:
+		return nil, trace.BadParameter("received unknown frame type %d from source", data[0])
+	}
+}
+
 // Write consumes all data provided and sends it on
 // the grpc stream. To prevent exhausting the stream all
-// sends on the stream are limited to be at most MaxChunkSize.
-// If the data exceeds the MaxChunkSize it will be sent in
-// batches.
+// sends on the stream are limited to be at most the configured
+// chunk size. If the data exceeds the chunk size it will be
+// sent in batches. When flow control is enabled, Write blocks
+// once too many bytes are outstanding and unacknowledged by the peer.
 func (c *ReadWriter) Write(b []byte) (int, error) {
 	c.wLock.Lock()
 	defer c.wLock.Unlock()
 
+	if c.cfg.Handshake {
+		if err := c.sendHandshakeOnce(); err != nil {
+			return 0, trace.Wrap(err)
+		}
+	}
+
+	// Read once under flowMu: by this point sendHandshakeOnce (if
+	// enabled) has already waited for negotiation to finish and
+	// applyHandshake refuses to apply a second time, so ChunkSize is
+	// stable for the rest of this call. Reading it without the lock
+	// here would otherwise race with applyHandshake mutating it from
+	// the Read goroutine.
+	chunkSize := c.chunkSize()
+
 	var sent int
 	for len(b) > 0 {
 		chunk := b
-		if len(chunk) > MaxChunkSize {
-			chunk = chunk[:MaxChunkSize]
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if c.cfg.FlowControl {
+			if err := c.awaitWriteCredit(len(chunk)); err != nil {
+				return sent, trace.Wrap(err)
+			}
+		}
+
+		frame := chunk
+		if c.cfg.FlowControl {
+			frame = append([]byte{byte(frameData)}, chunk...)
 		}
 
-		if err := c.source.Send(chunk); err != nil {
+		if err := c.send(frame); err != nil {
 			return sent, trace.ConnectionProblem(err, "failed to send on source")
 		}
 
@@ -121,6 +320,214 @@ func (c *ReadWriter) Write(b []byte) (int, error) {
 	return sent, nil
 }
 
+// chunkSize returns the data chunk size to use for this Write call.
+// When Handshake is enabled it is read under flowMu since applyHandshake
+// mutates it concurrently from the Read goroutine.
+func (c *ReadWriter) chunkSize() int {
+	if !c.cfg.Handshake {
+		return c.cfg.ChunkSize
+	}
+	c.flowMu.Lock()
+	defer c.flowMu.Unlock()
+	return c.cfg.ChunkSize
+}
+
+// send serializes access to source.Send across both data frames written
+// by Write and control frames written by Read/handshake.
+func (c *ReadWriter) send(frame []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.source.Send(frame)
+}
+
+// awaitWriteCredit blocks until at most windowSize bytes are
+// outstanding and unacknowledged, then reserves n bytes of credit.
+func (c *ReadWriter) awaitWriteCredit(n int) error {
+	return c.waitUntil(func() bool {
+		if c.outstanding+n > c.windowSize {
+			return false
+		}
+		c.outstanding += n
+		return true
+	})
+}
+
+// waitUntil blocks until apply, called with flowMu held, reports that
+// the awaited condition holds and has applied any corresponding state
+// change. apply must not mutate state when it returns false.
+//
+// Between attempts, waitUntil tries to receive and process one frame
+// from the source itself via tryDrainIncoming, rather than only
+// sleeping on flowCond. Credit and handshake progress both depend on a
+// window-update or handshake frame arriving from the peer, which is
+// normally picked up by a concurrent call to Read - but a caller that
+// never calls Read (e.g. a pure Write-only sender such as io.Copy
+// writing into this ReadWriter) would otherwise block here forever, since
+// nothing would ever receive the peer's frame. See FlowControl's doc
+// comment for the concurrent-Read interaction.
+//
+// flowMu is held for the whole loop except around the drain attempt,
+// and apply is always re-checked immediately after flowMu is
+// reacquired, before any Wait(): releasing flowMu to drain and then
+// blindly calling Wait() afterward would open a window where a
+// grantWriteCredit/applyHandshake Broadcast landing between the
+// release and the re-lock is missed - nothing is parked in Wait() yet
+// to observe it, and no further broadcast is guaranteed. Re-checking
+// under the same critical section that calls Wait() closes that gap.
+func (c *ReadWriter) waitUntil(apply func() bool) error {
+	c.flowMu.Lock()
+	defer c.flowMu.Unlock()
+
+	for !apply() {
+		c.flowMu.Unlock()
+		drained, err := c.tryDrainIncoming()
+		c.flowMu.Lock()
+		if err != nil {
+			return err
+		}
+		if drained {
+			continue
+		}
+		if !apply() {
+			c.flowCond.Wait()
+		}
+	}
+	return nil
+}
+
+// tryDrainIncoming receives and processes a single frame from the
+// source if no concurrent Read call currently owns rLock, returning
+// true if it did so. It yields immediately (returning false, nil)
+// rather than contending with an in-progress Read.
+func (c *ReadWriter) tryDrainIncoming() (bool, error) {
+	if !c.rLock.TryLock() {
+		return false, nil
+	}
+	defer c.rLock.Unlock()
+
+	data, err := c.recvFrame()
+	if err != nil {
+		return false, err
+	}
+	// A data frame received this way belongs to the caller's next Read,
+	// not to us; buffer it in place of the normal Read loop.
+	if data != nil {
+		c.rBytes = append(c.rBytes, data...)
+	}
+	return true, nil
+}
+
+// grantWriteCredit is called when a window-update frame arrives from
+// the peer, freeing up previously reserved write credit.
+func (c *ReadWriter) grantWriteCredit(n int) {
+	c.flowMu.Lock()
+	c.outstanding -= n
+	if c.outstanding < 0 {
+		c.outstanding = 0
+	}
+	c.flowMu.Unlock()
+	c.flowCond.Broadcast()
+}
+
+// ackRead tracks bytes consumed by Read and, once enough have
+// accumulated, sends a window-update frame granting the peer's writer
+// more credit.
+func (c *ReadWriter) ackRead(n int) error {
+	c.flowMu.Lock()
+	c.readUnacked += n
+	threshold := c.windowSize / 2
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if c.readUnacked < threshold {
+		c.flowMu.Unlock()
+		return nil
+	}
+	credit := c.readUnacked
+	c.readUnacked = 0
+	c.flowMu.Unlock()
+
+	buf := make([]byte, 9)
+	buf[0] = byte(frameWindowUpdate)
+	binary.BigEndian.PutUint64(buf[1:], uint64(credit))
+	if err := c.send(buf); err != nil {
+		return trace.ConnectionProblem(err, "failed to send window update")
+	}
+	return nil
+}
+
+// sendHandshakeOnce sends this side's proposed ChunkSize/WindowSize the
+// first time it is called, then waits for the peer's handshake frame,
+// which is consumed transparently by Read (or opportunistically by this
+// call itself, see waitUntil) and applied via applyHandshake.
+//
+// Sending and waiting are gated on separate flags (handshakeSent vs.
+// handshakeReceived) rather than one combined flag: a peer that opts
+// into Handshake sends its handshake as the very first frame, before
+// any data. If this side's Read consumes that frame before this side's
+// first Write, a single "handshakeDone" flag would already be true by
+// the time sendHandshakeOnce runs, and it would return without ever
+// sending this side's handshake - so the peer would never learn this
+// side's ChunkSize/WindowSize.
+func (c *ReadWriter) sendHandshakeOnce() error {
+	c.flowMu.Lock()
+	alreadySent := c.handshakeSent
+	c.handshakeSent = true
+	// Snapshot under flowMu: applyHandshake mutates both fields from the
+	// Read goroutine, and chunkSize() exists for exactly this reason -
+	// reading them here without the lock would be the same race.
+	chunkSize := c.cfg.ChunkSize
+	windowSize := c.windowSize
+	c.flowMu.Unlock()
+
+	if !alreadySent {
+		buf := make([]byte, 9)
+		buf[0] = byte(frameHandshake)
+		binary.BigEndian.PutUint32(buf[1:5], uint32(chunkSize))
+		binary.BigEndian.PutUint32(buf[5:9], uint32(windowSize))
+		if err := c.send(buf); err != nil {
+			return trace.ConnectionProblem(err, "failed to send handshake")
+		}
+	}
+
+	return c.waitUntil(func() bool {
+		return c.handshakeReceived
+	})
+}
+
+// applyHandshake negotiates ChunkSize and WindowSize down to the
+// smaller of this side's configuration and the peer's proposal. Only
+// the first handshake frame received is applied; a repeated (or
+// malicious) peer handshake frame after that is ignored rather than
+// re-negotiating mid-stream.
+func (c *ReadWriter) applyHandshake(payload []byte) {
+	c.flowMu.Lock()
+	if c.handshakeReceived {
+		c.flowMu.Unlock()
+		return
+	}
+
+	peerChunkSize := int(binary.BigEndian.Uint32(payload[0:4]))
+	peerWindowSize := int(binary.BigEndian.Uint32(payload[4:8]))
+	if peerChunkSize > 0 && peerChunkSize < c.cfg.ChunkSize {
+		c.cfg.ChunkSize = peerChunkSize
+	}
+	if peerWindowSize > 0 && peerWindowSize < c.windowSize {
+		c.windowSize = peerWindowSize
+	}
+	// The peer's window and chunk size are negotiated independently, so
+	// clamp the window back up if it ended up smaller than the
+	// (possibly also just-lowered) chunk size - otherwise no chunk
+	// would ever fit inside a single window and awaitWriteCredit would
+	// block on the first Write forever.
+	if c.windowSize < c.cfg.ChunkSize {
+		c.windowSize = c.cfg.ChunkSize
+	}
+	c.handshakeReceived = true
+	c.flowMu.Unlock()
+	c.flowCond.Broadcast()
+}
+
 // Close cleans up resources used by the stream.
 func (c *ReadWriter) Close() error {
 	var err error
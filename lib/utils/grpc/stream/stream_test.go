@@ -0,0 +1,210 @@
+// Copyright 2023 Gravitational, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pipeSource connects two ReadWriters back to back over a pair of
+// channels, the way a real bidirectional gRPC stream would, so tests can
+// exercise flow control with a genuinely concurrent peer instead of a
+// single-process loopback.
+type pipeSource struct {
+	send chan<- []byte
+	recv <-chan []byte
+	done <-chan struct{}
+}
+
+// newPipe returns a connected pair of Sources. closeFn unblocks any
+// in-flight Send/Recv calls on either side with io.EOF.
+func newPipe() (a, b *pipeSource, closeFn func()) {
+	ab := make(chan []byte, 64)
+	ba := make(chan []byte, 64)
+	done := make(chan struct{})
+	a = &pipeSource{send: ab, recv: ba, done: done}
+	b = &pipeSource{send: ba, recv: ab, done: done}
+	return a, b, func() { close(done) }
+}
+
+func (p *pipeSource) Send(b []byte) error {
+	cp := append([]byte(nil), b...)
+	select {
+	case p.send <- cp:
+		return nil
+	case <-p.done:
+		return io.EOF
+	}
+}
+
+func (p *pipeSource) Recv() ([]byte, error) {
+	select {
+	case b, ok := <-p.recv:
+		if !ok {
+			return nil, io.EOF
+		}
+		return b, nil
+	case <-p.done:
+		return nil, io.EOF
+	}
+}
+
+func TestConfigCheckAndSetDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "zero value gets defaults",
+			cfg:  Config{},
+		},
+		{
+			name:    "chunk size out of range rejected",
+			cfg:     Config{ChunkSize: maxChunkSize * 2},
+			wantErr: true,
+		},
+		{
+			name:    "window smaller than chunk size rejected",
+			cfg:     Config{ChunkSize: maxChunkSize, WindowSize: minChunkSize},
+			wantErr: true,
+		},
+		{
+			name: "window equal to chunk size accepted",
+			cfg:  Config{ChunkSize: maxChunkSize, WindowSize: maxChunkSize},
+		},
+		{
+			name:    "handshake without flow control rejected",
+			cfg:     Config{Handshake: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.CheckAndSetDefaults()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestApplyHandshakeClampsWindowToChunkSize verifies that a peer
+// proposing a WindowSize smaller than the negotiated ChunkSize doesn't
+// leave the ReadWriter with a window too small to ever admit a single
+// chunk, which would otherwise block awaitWriteCredit on the first
+// Write forever.
+func TestApplyHandshakeClampsWindowToChunkSize(t *testing.T) {
+	a, _, closePipe := newPipe()
+	defer closePipe()
+
+	rw, err := NewReadWriterConfig(a, Config{
+		ChunkSize:   maxChunkSize,
+		WindowSize:  maxChunkSize,
+		FlowControl: true,
+		Handshake:   true,
+	})
+	require.NoError(t, err)
+
+	payload := make([]byte, 9)
+	payload[0] = byte(frameHandshake)
+	binary.BigEndian.PutUint32(payload[1:5], uint32(minChunkSize))
+	binary.BigEndian.PutUint32(payload[5:9], uint32(1024)) // smaller than the negotiated chunk size
+	rw.applyHandshake(payload[1:])
+
+	require.Equal(t, minChunkSize, rw.cfg.ChunkSize)
+	require.GreaterOrEqual(t, rw.windowSize, rw.cfg.ChunkSize)
+}
+
+// TestReadWriterFlowControlConcurrentRead reproduces the scenario
+// described in FlowControl's doc comment: a ReadWriter used by a
+// bidirectional proxy has a goroutine permanently parked in Read
+// (processing whatever control frames arrive), while a concurrent Write
+// blocks waiting for write credit. Write credit only becomes available
+// once the peer's window-update frame is received and processed by that
+// same Read goroutine, so awaitWriteCredit must be woken by its
+// Broadcast rather than missing it - a prior version of waitUntil had a
+// check-then-wait window where exactly that broadcast could be lost,
+// deadlocking Write permanently.
+func TestReadWriterFlowControlConcurrentRead(t *testing.T) {
+	a, b, closePipe := newPipe()
+	defer closePipe()
+
+	cfg := Config{ChunkSize: minChunkSize, WindowSize: minChunkSize, FlowControl: true}
+	rwA, err := NewReadWriterConfig(a, cfg)
+	require.NoError(t, err)
+	rwB, err := NewReadWriterConfig(b, cfg)
+	require.NoError(t, err)
+
+	// A's Read goroutine never sees application data in this test (B
+	// never writes back), only the window-update control frames B's
+	// Read sends as it consumes A's data. It mirrors the idle side of a
+	// bidirectional proxy: parked in Read, holding rLock, so
+	// tryDrainIncoming can never grab it and awaitWriteCredit depends
+	// entirely on this goroutine's own Broadcast.
+	go func() {
+		buf := make([]byte, minChunkSize)
+		for {
+			if _, err := rwA.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	payload := bytes.Repeat([]byte{'x'}, minChunkSize*6)
+
+	received := make([]byte, 0, len(payload))
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		buf := make([]byte, minChunkSize)
+		for len(received) < len(payload) {
+			n, err := rwB.Read(buf)
+			received = append(received, buf[:n]...)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := rwA.Write(payload)
+		writeDone <- err
+	}()
+
+	select {
+	case err := <-writeDone:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Write did not complete: awaitWriteCredit likely missed a window-update broadcast")
+	}
+
+	select {
+	case <-readDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Read on the peer did not receive the full payload in time")
+	}
+
+	require.Equal(t, payload, received)
+}